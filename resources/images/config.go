@@ -0,0 +1,155 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package images
+
+import (
+	"image/draw"
+	"image/png"
+
+	"github.com/disintegration/gift"
+)
+
+const (
+	defaultJPEGQuality    = 75
+	defaultResampleFilter = "box"
+	defaultBgColor        = "#ffffff"
+)
+
+// Imaging contains default image processing configuration. This will be
+// fetched from site (or language) config.
+type Imaging struct {
+	// Default image quality setting (1-100). Used for JPEG and lossy WebP images.
+	Quality int
+
+	// Resample filter used. See https://github.com/disintegration/imaging
+	ResampleFilter string
+
+	// The anchor used in Fill. Default is "smart", meaning Smart Crop.
+	Anchor string
+
+	// Default color used in fill operations, expressed as an RGB hex string.
+	BgColor string
+
+	// AutoOrient, if enabled, rotates/flips images on load to undo the
+	// rotation implied by their EXIF Orientation tag, so e.g. portrait phone
+	// photos don't come out sideways.
+	AutoOrient bool
+
+	// StripMetadata, if enabled, drops EXIF and ICC metadata from processed
+	// images instead of carrying it through to the encoded output. Useful
+	// for privacy-conscious sites that don't want to publish e.g. GPS tags.
+	StripMetadata bool
+}
+
+// ImageConfig holds configuration to resize, fill, fit or rotate an image,
+// as produced by the image processing shortcodes/pipes.
+type ImageConfig struct {
+	// Action is one of "resize", "fill" or "fit".
+	Action string
+
+	Width  int
+	Height int
+
+	Quality int
+
+	// Lossless turns on lossless compression for formats that support both
+	// lossy and lossless encoding (currently WebP). It is ignored for
+	// formats that are always lossless, such as PNG and GIF.
+	Lossless bool
+
+	Rotate int
+
+	AnchorStr string
+	Anchor    gift.Anchor
+
+	Filter    gift.Resampling
+	FilterStr string
+
+	// AutoOrient, if enabled, applies the rotate/flip filter implied by
+	// Orientation before any other filter. Orientation and Metadata are
+	// populated by the caller from the source Image's Metadata.
+	AutoOrient  bool
+	Orientation int
+	Metadata    Metadata
+
+	// StripMetadata, if enabled, omits Metadata's EXIF/ICC data from the
+	// encoded output.
+	StripMetadata bool
+
+	// Progressive, if true and the target format is JPEG, produces a
+	// multi-scan progressive JPEG instead of a baseline sequential one.
+	Progressive bool
+
+	// Interlace, if true and the target format is PNG, produces an
+	// Adam7-interlaced PNG instead of a non-interlaced one.
+	Interlace bool
+
+	// PNG holds encoder options specific to the PNG format. Ignored unless
+	// the target format is PNG.
+	PNG PNGConfig
+
+	// GIF holds encoder options specific to the GIF format. Ignored unless
+	// the target format is GIF.
+	GIF GIFConfig
+}
+
+// PNGConfig holds PNG-specific encoding options.
+type PNGConfig struct {
+	// CompressionLevel controls the zlib compression level used by the PNG
+	// encoder. The zero value is png.DefaultCompression. It is not used when
+	// Optimize or Interlace is set.
+	CompressionLevel png.CompressionLevel
+
+	// Optimize tries several encoding strategies - including an external
+	// zopflipng or oxipng binary when one is available on PATH - and keeps
+	// whichever output is smallest, mirroring what those tools offer as a
+	// standalone post-processing step. It is slower than a single encode
+	// pass and best reserved for production builds.
+	Optimize bool
+}
+
+// GIFConfig holds GIF-specific encoding options.
+type GIFConfig struct {
+	// NumColors is the maximum palette size used when quantizing the image.
+	// The zero value defaults to 256.
+	NumColors int
+
+	// Quantizer, when set, overrides the default palette quantizer used to
+	// reduce the image to NumColors colors.
+	Quantizer draw.Quantizer
+
+	// Drawer, when set, overrides the default ditherer used when mapping the
+	// image onto the quantized palette.
+	Drawer draw.Drawer
+}
+
+// imageFormats maps a lower-cased file extension, including the leading dot,
+// to its Format.
+var imageFormats = map[string]Format{
+	".jpg":  JPEG,
+	".jpeg": JPEG,
+	".png":  PNG,
+	".tif":  TIFF,
+	".tiff": TIFF,
+	".bmp":  BMP,
+	".gif":  GIF,
+	".webp": WEBP,
+}
+
+// ImageFormatFromExt returns the Format matching the given file extension
+// (including the leading dot), and whether it was found.
+func ImageFormatFromExt(ext string) (Format, bool) {
+	f, found := imageFormats[ext]
+	return f, found
+}
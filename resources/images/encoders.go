@@ -0,0 +1,149 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package images
+
+import (
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+// EncoderFunc encodes img, honoring conf, to w. Third-party modules register
+// one of these with RegisterEncoder to add support for a format without
+// patching this package.
+type EncoderFunc func(conf ImageConfig, img image.Image, w io.Writer) error
+
+// DecoderFunc returns the image.Config (width, height, color model) for the
+// image read from r. It is used by initConfig to resolve Width/Height for
+// formats registered with RegisterDecoder.
+type DecoderFunc func(r io.Reader) (image.Config, error)
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[Format]EncoderFunc{}
+
+	decodersMu sync.RWMutex
+	decoders   = map[Format]DecoderFunc{}
+)
+
+// RegisterEncoder registers enc as the encoder for f, replacing any encoder
+// previously registered for that format. It is typically called from the
+// init function of a package adding support for an additional image format.
+func RegisterEncoder(f Format, enc EncoderFunc) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	encoders[f] = enc
+}
+
+// RegisterDecoder registers dec as the config decoder for f, replacing any
+// decoder previously registered for that format.
+func RegisterDecoder(f Format, dec DecoderFunc) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders[f] = dec
+}
+
+func init() {
+	RegisterEncoder(JPEG, encodeJPEG)
+	RegisterEncoder(PNG, encodePNG)
+	RegisterEncoder(GIF, encodeGIF)
+	RegisterEncoder(TIFF, encodeTIFF)
+	RegisterEncoder(BMP, encodeBMP)
+	RegisterEncoder(WEBP, encodeWebP)
+}
+
+func encodeJPEG(conf ImageConfig, img image.Image, w io.Writer) error {
+	var rgba *image.RGBA
+	quality := conf.Quality
+
+	if nrgba, ok := img.(*image.NRGBA); ok {
+		if nrgba.Opaque() {
+			rgba = &image.RGBA{
+				Pix:    nrgba.Pix,
+				Stride: nrgba.Stride,
+				Rect:   nrgba.Rect,
+			}
+		}
+	}
+	src := img
+	if rgba != nil {
+		src = rgba
+	}
+
+	if conf.Progressive {
+		// The standard library's encoder only ever produces baseline
+		// sequential JPEGs; progressive mode is delegated to
+		// encodeProgressiveJPEG, whose implementation depends on whether
+		// this binary was built with cgo (see jpeg_progressive_*.go).
+		return encodeProgressiveJPEG(quality, src, w)
+	}
+
+	return jpeg.Encode(w, src, &jpeg.Options{Quality: quality})
+}
+
+func encodePNG(conf ImageConfig, img image.Image, w io.Writer) error {
+	if conf.PNG.Optimize && conf.Interlace {
+		// encodeOptimizedPNG's external-optimizer and multi-level passes
+		// don't know how to produce an interlaced file, so combining the
+		// two would silently drop Interlace. Reject it instead of guessing
+		// which one the caller actually wanted.
+		return errors.New("images: PNG.Optimize and Interlace cannot be combined")
+	}
+
+	if conf.PNG.Optimize {
+		return encodeOptimizedPNG(conf, img, w)
+	}
+
+	if conf.Interlace {
+		rgba, ok := img.(*image.RGBA)
+		if !ok {
+			b := img.Bounds()
+			converted := image.NewRGBA(b)
+			draw.Draw(converted, b, img, b.Min, draw.Src)
+			rgba = converted
+		}
+		return encodeInterlacedPNG(rgba, w)
+	}
+
+	encoder := png.Encoder{CompressionLevel: conf.PNG.CompressionLevel}
+	return encoder.Encode(w, img)
+}
+
+func encodeGIF(conf ImageConfig, img image.Image, w io.Writer) error {
+	numColors := conf.GIF.NumColors
+	if numColors == 0 {
+		numColors = 256
+	}
+	return gif.Encode(w, img, &gif.Options{
+		NumColors: numColors,
+		Quantizer: conf.GIF.Quantizer,
+		Drawer:    conf.GIF.Drawer,
+	})
+}
+
+func encodeTIFF(conf ImageConfig, img image.Image, w io.Writer) error {
+	return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate, Predictor: true})
+}
+
+func encodeBMP(conf ImageConfig, img image.Image, w io.Writer) error {
+	return bmp.Encode(w, img)
+}
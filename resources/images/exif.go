@@ -0,0 +1,424 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package images
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/disintegration/gift"
+	"github.com/pkg/errors"
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// Metadata holds the subset of a source image's EXIF and ICC metadata that
+// Hugo knows how to read and, unless StripMetadata is set, carry through to
+// the encoded output. It is populated once, alongside Width/Height, in
+// initConfig.
+type Metadata struct {
+	// Orientation is the raw EXIF orientation tag (1-8), or 0 if the source
+	// had none. It drives AutoOrient.
+	Orientation int
+
+	// ICCProfile holds the raw ICC color profile, if any.
+	ICCProfile []byte
+
+	// EXIF holds the raw APP1 Exif segment, including its "Exif\0\0" header,
+	// if any. It is reinjected into JPEG output unless StripMetadata is set,
+	// verbatim except that its Orientation is reset to 1 when AutoOrient is
+	// set.
+	EXIF []byte
+
+	// Copyright is the EXIF Copyright tag, if any.
+	Copyright string
+
+	// DateTimeOriginal is the EXIF DateTimeOriginal tag or, when that is
+	// absent, the DateTime tag, if any.
+	DateTimeOriginal time.Time
+
+	// Latitude and Longitude hold the EXIF GPS position. HasGPS is false
+	// when the source had no GPS tags.
+	Latitude, Longitude float64
+	HasGPS              bool
+}
+
+// loadMetadata extracts the Metadata for a source image of format f from its
+// raw bytes. Formats without a metadata container of their own (GIF, TIFF,
+// BMP, WebP) simply yield a zero Metadata.
+func loadMetadata(f Format, data []byte) Metadata {
+	var md Metadata
+
+	switch f {
+	case JPEG:
+		md.EXIF = parseJPEGEXIFSegment(data)
+		md.ICCProfile = parseJPEGICCProfile(data)
+	case PNG:
+		md.ICCProfile = parsePNGICCProfile(data)
+	default:
+		return md
+	}
+
+	if len(md.EXIF) == 0 {
+		return md
+	}
+
+	x, err := exif.Decode(bytes.NewReader(md.EXIF))
+	if err != nil {
+		// Malformed or partial EXIF shouldn't fail the whole decode.
+		return md
+	}
+
+	if tag, err := x.Get(exif.Orientation); err == nil {
+		if v, err := tag.Int(0); err == nil {
+			md.Orientation = v
+		}
+	}
+	if tag, err := x.Get(exif.Copyright); err == nil {
+		if v, err := tag.StringVal(); err == nil {
+			md.Copyright = v
+		}
+	}
+	if t, err := x.DateTime(); err == nil {
+		md.DateTimeOriginal = t
+	}
+	if lat, long, err := x.LatLong(); err == nil {
+		md.Latitude, md.Longitude, md.HasGPS = lat, long, true
+	}
+
+	return md
+}
+
+// orientationFilters returns the gift filters needed to undo the rotation or
+// mirroring implied by an EXIF Orientation tag value, in application order.
+func orientationFilters(orientation int) []gift.Filter {
+	switch orientation {
+	case 2:
+		return []gift.Filter{gift.FlipHorizontal()}
+	case 3:
+		return []gift.Filter{gift.Rotate180()}
+	case 4:
+		return []gift.Filter{gift.FlipVertical()}
+	case 5:
+		return []gift.Filter{gift.Transpose()}
+	case 6:
+		return []gift.Filter{gift.Rotate270()}
+	case 7:
+		return []gift.Filter{gift.Transverse()}
+	case 8:
+		return []gift.Filter{gift.Rotate90()}
+	default:
+		return nil
+	}
+}
+
+// parseJPEGEXIFSegment returns the first APP1 "Exif\0\0" segment in a JPEG
+// byte stream, including its header, or nil if there is none.
+func parseJPEGEXIFSegment(data []byte) []byte {
+	var found []byte
+	walkJPEGSegments(data, func(marker byte, payload []byte) bool {
+		if marker == 0xE1 && len(payload) > 6 && string(payload[:6]) == "Exif\x00\x00" {
+			found = payload
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// parseJPEGICCProfile reassembles an ICC profile split across one or more
+// APP2 "ICC_PROFILE\0" segments, per the ICC spec's chunking scheme.
+func parseJPEGICCProfile(data []byte) []byte {
+	type chunk struct {
+		seq, total byte
+		data       []byte
+	}
+	var chunks []chunk
+
+	walkJPEGSegments(data, func(marker byte, payload []byte) bool {
+		if marker == 0xE2 && len(payload) > 14 && string(payload[:12]) == "ICC_PROFILE\x00" {
+			chunks = append(chunks, chunk{seq: payload[12], total: payload[13], data: payload[14:]})
+		}
+		return true
+	})
+
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].seq < chunks[j].seq })
+
+	var profile []byte
+	for _, c := range chunks {
+		profile = append(profile, c.data...)
+	}
+	return profile
+}
+
+// walkJPEGSegments calls fn for every marker segment after the SOI, stopping
+// at the start of scan (or when fn returns false).
+func walkJPEGSegments(data []byte, fn func(marker byte, payload []byte) bool) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return
+		}
+		marker := data[pos+1]
+		if marker == 0xD8 || marker == 0xD9 || marker == 0xDA {
+			// SOI, EOI or start of entropy-coded scan data: no more markers.
+			return
+		}
+		segLen := int(data[pos+2])<<8 | int(data[pos+3])
+		if segLen < 2 || pos+2+segLen > len(data) {
+			return
+		}
+		if !fn(marker, data[pos+4:pos+2+segLen]) {
+			return
+		}
+		pos += 2 + segLen
+	}
+}
+
+// parsePNGICCProfile returns the (inflated) ICC profile stored in a PNG's
+// iCCP chunk, or nil if it has none.
+func parsePNGICCProfile(data []byte) []byte {
+	const sig = "\x89PNG\r\n\x1a\n"
+	if len(data) < 8 || string(data[:8]) != sig {
+		return nil
+	}
+
+	pos := 8
+	for pos+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		typ := string(data[pos+4 : pos+8])
+		if pos+12+length > len(data) {
+			return nil
+		}
+		chunkData := data[pos+8 : pos+8+length]
+
+		if typ == "iCCP" {
+			nul := bytes.IndexByte(chunkData, 0)
+			if nul < 0 || nul+2 > len(chunkData) {
+				return nil
+			}
+			r, err := zlib.NewReader(bytes.NewReader(chunkData[nul+2:]))
+			if err != nil {
+				return nil
+			}
+			defer r.Close()
+			profile, err := io.ReadAll(r)
+			if err != nil {
+				return nil
+			}
+			return profile
+		}
+		if typ == "IDAT" {
+			return nil
+		}
+		pos += 12 + length
+	}
+
+	return nil
+}
+
+// orientationTag is the EXIF tag ID for Orientation (0x0112).
+const orientationTag = 0x0112
+
+// neutralizeEXIFOrientation returns a copy of exifData with its Orientation
+// tag, if any, set to 1 (normal). It is used when AutoOrient has already
+// physically rotated/flipped the pixels, so the preserved EXIF doesn't tell
+// a compliant viewer to rotate the now-already-upright image a second time.
+func neutralizeEXIFOrientation(exifData []byte) []byte {
+	if len(exifData) < 6+8 || string(exifData[:6]) != "Exif\x00\x00" {
+		return exifData
+	}
+	tiff := exifData[6:]
+
+	var order binary.ByteOrder
+	switch {
+	case bytes.HasPrefix(tiff, []byte("II")):
+		order = binary.LittleEndian
+	case bytes.HasPrefix(tiff, []byte("MM")):
+		order = binary.BigEndian
+	default:
+		return exifData
+	}
+	if len(tiff) < 8 {
+		return exifData
+	}
+
+	ifdOffset := order.Uint32(tiff[4:8])
+	if int(ifdOffset)+2 > len(tiff) {
+		return exifData
+	}
+	count := int(order.Uint16(tiff[ifdOffset : ifdOffset+2]))
+	entriesStart := int(ifdOffset) + 2
+
+	out := append([]byte(nil), exifData...)
+	outTIFF := out[6:]
+
+	for e := 0; e < count; e++ {
+		entryStart := entriesStart + e*12
+		if entryStart+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entryStart:entryStart+2]) == orientationTag {
+			valueStart := entryStart + 8
+			order.PutUint16(outTIFF[valueStart:valueStart+2], 1)
+			break
+		}
+	}
+
+	return out
+}
+
+// writeWithMetadata copies encoded (the output of an EncoderFunc) to w,
+// reinjecting md's EXIF and ICC data for formats that support carrying it.
+func writeWithMetadata(f Format, md Metadata, encoded []byte, w io.Writer) error {
+	switch f {
+	case JPEG:
+		return writeJPEGMetadata(md, encoded, w)
+	case PNG:
+		return writePNGMetadata(md, encoded, w)
+	default:
+		_, err := w.Write(encoded)
+		return err
+	}
+}
+
+func writeJPEGMetadata(md Metadata, encoded []byte, w io.Writer) error {
+	if len(encoded) < 2 || encoded[0] != 0xFF || encoded[1] != 0xD8 {
+		_, err := w.Write(encoded)
+		return err
+	}
+
+	if _, err := w.Write(encoded[:2]); err != nil {
+		return err
+	}
+	if len(md.EXIF) > 0 {
+		if err := writeJPEGSegment(w, 0xE1, md.EXIF); err != nil {
+			return err
+		}
+	}
+	if len(md.ICCProfile) > 0 {
+		if err := writeJPEGICCSegments(w, md.ICCProfile); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write(encoded[2:])
+	return err
+}
+
+func writeJPEGSegment(w io.Writer, marker byte, payload []byte) error {
+	segLen := len(payload) + 2
+	if segLen > 0xFFFF {
+		return errors.New("metadata segment too large for a single JPEG marker")
+	}
+	if _, err := w.Write([]byte{0xFF, marker, byte(segLen >> 8), byte(segLen)}); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// writeJPEGICCSegments splits profile across as many APP2 segments as
+// required, per the ICC spec's chunking scheme.
+func writeJPEGICCSegments(w io.Writer, profile []byte) error {
+	const header = "ICC_PROFILE\x00"
+	const maxChunk = 0xFFFF - 2 - len(header) - 2
+
+	total := (len(profile) + maxChunk - 1) / maxChunk
+	if total == 0 {
+		total = 1
+	}
+
+	for i := 0; i < total; i++ {
+		start := i * maxChunk
+		end := start + maxChunk
+		if end > len(profile) {
+			end = len(profile)
+		}
+
+		payload := append([]byte(header), byte(i+1), byte(total))
+		payload = append(payload, profile[start:end]...)
+		if err := writeJPEGSegment(w, 0xE2, payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writePNGMetadata(md Metadata, encoded []byte, w io.Writer) error {
+	if len(md.ICCProfile) == 0 {
+		_, err := w.Write(encoded)
+		return err
+	}
+
+	// The signature (8 bytes) is always followed by a fixed-size, 13-byte
+	// IHDR chunk. iCCP must come before the first IDAT, so insert it right
+	// after IHDR.
+	ihdrEnd := 8 + 8 + 13 + 4
+	if len(encoded) < ihdrEnd {
+		_, err := w.Write(encoded)
+		return err
+	}
+
+	if _, err := w.Write(encoded[:ihdrEnd]); err != nil {
+		return err
+	}
+	if err := writePNGChunk(w, "iCCP", pngICCPData(md.ICCProfile)); err != nil {
+		return err
+	}
+	_, err := w.Write(encoded[ihdrEnd:])
+	return err
+}
+
+func pngICCPData(profile []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("icc") // profile name
+	buf.WriteByte(0)       // name terminator
+	buf.WriteByte(0)       // compression method: zlib
+	zw := zlib.NewWriter(&buf)
+	zw.Write(profile)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func writePNGChunk(w io.Writer, typ string, data []byte) error {
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(data)))
+	if _, err := w.Write(lenBuf[:]); err != nil {
+		return err
+	}
+
+	body := append([]byte(typ), data...)
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+
+	var crcBuf [4]byte
+	binary.BigEndian.PutUint32(crcBuf[:], crc32.ChecksumIEEE(body))
+	_, err := w.Write(crcBuf[:])
+	return err
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"testing"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// buildTestEXIF returns a minimal, well-formed "Exif\0\0" APP1 payload
+// (little-endian TIFF, a single 0th-IFD entry) carrying only an Orientation
+// tag, for use in tests that don't want to depend on real JPEG fixtures.
+func buildTestEXIF(orientation uint16) []byte {
+	const ifdOffset = 8
+
+	buf := make([]byte, ifdOffset+2+12+4)
+	copy(buf, "II")
+	binary.LittleEndian.PutUint16(buf[2:4], 42)
+	binary.LittleEndian.PutUint32(buf[4:8], ifdOffset)
+
+	binary.LittleEndian.PutUint16(buf[ifdOffset:ifdOffset+2], 1) // entry count
+
+	entry := buf[ifdOffset+2:]
+	binary.LittleEndian.PutUint16(entry[0:2], orientationTag)
+	binary.LittleEndian.PutUint16(entry[2:4], 3) // type: SHORT
+	binary.LittleEndian.PutUint32(entry[4:8], 1) // count
+	binary.LittleEndian.PutUint16(entry[8:10], orientation)
+
+	return append([]byte("Exif\x00\x00"), buf...)
+}
+
+func readTestOrientation(t *testing.T, exifData []byte) int {
+	t.Helper()
+	x, err := exif.Decode(bytes.NewReader(exifData))
+	if err != nil {
+		t.Fatalf("exif.Decode: %v", err)
+	}
+	tag, err := x.Get(exif.Orientation)
+	if err != nil {
+		t.Fatalf("no Orientation tag: %v", err)
+	}
+	v, err := tag.Int(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestNeutralizeEXIFOrientation(t *testing.T) {
+	in := buildTestEXIF(6)
+	if got := readTestOrientation(t, in); got != 6 {
+		t.Fatalf("fixture sanity check failed: got %d, want 6", got)
+	}
+
+	out := neutralizeEXIFOrientation(in)
+	if got := readTestOrientation(t, out); got != 1 {
+		t.Fatalf("got Orientation %d, want 1", got)
+	}
+}
+
+// TestGetDefaultImageConfigWiring exercises the wiring end to end: a source
+// image with an EXIF Orientation of 6, processed with AutoOrient, must come
+// out with its EXIF Orientation tag reset to 1 so a compliant viewer doesn't
+// rotate the already-upright pixels a second time.
+func TestGetDefaultImageConfigWiring(t *testing.T) {
+	exifData := buildTestEXIF(6)
+
+	src := &Image{
+		Format: JPEG,
+		imageConfig: &imageConfig{
+			configLoaded: true,
+			metadata:     Metadata{Orientation: 6, EXIF: exifData},
+		},
+	}
+
+	proc := &ImageProcessor{Cfg: Imaging{AutoOrient: true}}
+	conf := proc.GetDefaultImageConfig("resize", src)
+
+	if !conf.AutoOrient {
+		t.Fatal("expected AutoOrient to be inherited from Imaging")
+	}
+	if conf.Orientation != 6 {
+		t.Fatalf("got Orientation %d, want 6", conf.Orientation)
+	}
+	if len(conf.Metadata.EXIF) == 0 {
+		t.Fatal("expected conf.Metadata.EXIF to be populated from the source image")
+	}
+
+	if got := orientationFilters(conf.Orientation); len(got) != 1 {
+		t.Fatalf("expected one orientation filter for Orientation 6, got %d", len(got))
+	}
+
+	var buf bytes.Buffer
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	if err := src.EncodeTo(conf, img, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readTestOrientation(t, parseJPEGEXIFSegment(buf.Bytes()))
+	if got != 1 {
+		t.Fatalf("encoded output still has Orientation %d, want 1", got)
+	}
+}
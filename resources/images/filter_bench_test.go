@@ -0,0 +1,47 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package images
+
+import (
+	"image"
+	"testing"
+
+	"github.com/disintegration/gift"
+)
+
+// BenchmarkFilterSmall stays below tileFilterThreshold and always takes
+// Filter's single-Draw path, as a baseline to compare against
+// BenchmarkFilterLarge on multi-core builds.
+func BenchmarkFilterSmall(b *testing.B) {
+	benchmarkFilter(b, 1000, 900)
+}
+
+// BenchmarkFilterLarge exceeds tileFilterThreshold and takes Filter's tiled,
+// worker-pool path.
+func BenchmarkFilterLarge(b *testing.B) {
+	benchmarkFilter(b, 4000, 3000)
+}
+
+func benchmarkFilter(b *testing.B, width, height int) {
+	src := image.NewRGBA(image.Rect(0, 0, width, height))
+	p := &ImageProcessor{}
+	f := gift.Resize(width/2, height/2, gift.LanczosResampling)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Filter(src, f); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package images
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/gift"
+)
+
+// TestFilterTiledMatchesUntiled guards against the tiled path in Filter
+// diverging from the single-Draw path: it forces a destination above
+// tileFilterThreshold and checks the result is byte-for-byte identical to
+// what a single, untiled gift.Draw produces.
+func TestFilterTiledMatchesUntiled(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 3000, 2000))
+	for y := 0; y < src.Rect.Dy(); y++ {
+		for x := 0; x < src.Rect.Dx(); x++ {
+			src.Set(x, y, color.RGBA{
+				R: uint8(x % 256),
+				G: uint8(y % 256),
+				B: uint8((x + y) % 256),
+				A: 255,
+			})
+		}
+	}
+
+	f := gift.Resize(2200, 1600, gift.LanczosResampling)
+
+	p := &ImageProcessor{}
+	tiled, err := p.Filter(src, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := gift.New(f)
+	want := image.NewRGBA(g.Bounds(src.Bounds()))
+	g.Draw(want, src)
+
+	got, ok := tiled.(*image.RGBA)
+	if !ok {
+		t.Fatalf("expected *image.RGBA, got %T", tiled)
+	}
+
+	if got.Rect != want.Rect {
+		t.Fatalf("bounds mismatch: got %v, want %v", got.Rect, want.Rect)
+	}
+	if !bytes.Equal(got.Pix, want.Pix) {
+		t.Fatal("tiled Filter output does not match the untiled output")
+	}
+}
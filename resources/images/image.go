@@ -14,17 +14,18 @@
 package images
 
 import (
+	"bytes"
 	"image"
 	"image/color"
-	"image/gif"
-	"image/jpeg"
-	"image/png"
 	"io"
+	"runtime"
 	"sync"
 
 	"github.com/disintegration/gift"
-	"golang.org/x/image/bmp"
-	"golang.org/x/image/tiff"
+
+	// Register the WebP decoder and its DecodeConfig with the image package
+	// so initConfig can resolve Width/Height on WebP originals.
+	_ "golang.org/x/image/webp"
 
 	"github.com/gohugoio/hugo/common/hugio"
 	"github.com/pkg/errors"
@@ -55,43 +56,38 @@ type Image struct {
 	*imageConfig
 }
 
+// EncodeTo encodes img as i.Format, honoring conf, to w. The actual encoding
+// is delegated to the EncoderFunc registered for i.Format via
+// RegisterEncoder. Unless conf.StripMetadata is set, conf.Metadata is carried
+// through to the output: both its EXIF and ICC data for JPEG, only its ICC
+// profile for PNG. Other formats are written without metadata.
 func (i *Image) EncodeTo(conf ImageConfig, img image.Image, w io.Writer) error {
-	switch i.Format {
-	case JPEG:
-
-		var rgba *image.RGBA
-		quality := conf.Quality
-
-		if nrgba, ok := img.(*image.NRGBA); ok {
-			if nrgba.Opaque() {
-				rgba = &image.RGBA{
-					Pix:    nrgba.Pix,
-					Stride: nrgba.Stride,
-					Rect:   nrgba.Rect,
-				}
-			}
-		}
-		if rgba != nil {
-			return jpeg.Encode(w, rgba, &jpeg.Options{Quality: quality})
-		}
-		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
-	case PNG:
-		encoder := png.Encoder{CompressionLevel: png.DefaultCompression}
-		return encoder.Encode(w, img)
-
-	case GIF:
-		return gif.Encode(w, img, &gif.Options{
-			NumColors: 256,
-		})
-	case TIFF:
-		return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate, Predictor: true})
-
-	case BMP:
-		return bmp.Encode(w, img)
-	default:
+	encodersMu.RLock()
+	enc, found := encoders[i.Format]
+	encodersMu.RUnlock()
+
+	if !found {
 		return errors.New("format not supported")
 	}
 
+	if conf.StripMetadata || (len(conf.Metadata.EXIF) == 0 && len(conf.Metadata.ICCProfile) == 0) {
+		return enc(conf, img, w)
+	}
+
+	var buf bytes.Buffer
+	if err := enc(conf, img, &buf); err != nil {
+		return err
+	}
+
+	md := conf.Metadata
+	if conf.AutoOrient && len(md.EXIF) > 0 {
+		// The pixels have already been rotated/flipped to match
+		// Orientation; leaving the original tag in place would tell a
+		// compliant viewer to rotate the now-upright image a second time.
+		md.EXIF = neutralizeEXIFOrientation(md.EXIF)
+	}
+
+	return writeWithMetadata(i.Format, md, buf.Bytes(), w)
 }
 
 // Height returns i's height.
@@ -106,6 +102,12 @@ func (i *Image) Width() int {
 	return i.config.Width
 }
 
+// Metadata returns i's EXIF/ICC metadata, as captured from the source image.
+func (i *Image) Metadata() Metadata {
+	i.initConfig()
+	return i.metadata
+}
+
 func (i Image) WithImage(img image.Image) *Image {
 	i.Spec = nil
 	i.imageConfig = &imageConfig{
@@ -122,6 +124,14 @@ func (i Image) WithSpec(s Spec) *Image {
 	return &i
 }
 
+// metadataScanLimit bounds how much of a source file initConfig reads into
+// memory to scan for EXIF/ICC metadata. The JPEG and PNG specs require APPn
+// segments and ancillary chunks like iCCP to appear before the compressed
+// image data, so this comfortably covers real-world files without paying to
+// buffer a, possibly very large, whole original. The image.Config is decoded
+// from the file itself, as a TIFF's IFD may lie anywhere in the file.
+const metadataScanLimit = 4 << 20 // 4MiB
+
 func (i *Image) initConfig() error {
 	var err error
 	i.configInit.Do(func() {
@@ -130,8 +140,8 @@ func (i *Image) initConfig() error {
 		}
 
 		var (
-			f      hugio.ReadSeekCloser
-			config image.Config
+			f    hugio.ReadSeekCloser
+			data []byte
 		)
 
 		f, err = i.Spec.ReadSeekCloser()
@@ -140,11 +150,30 @@ func (i *Image) initConfig() error {
 		}
 		defer f.Close()
 
-		config, _, err = image.DecodeConfig(f)
+		data, err = io.ReadAll(io.LimitReader(f, metadataScanLimit))
+		if err != nil {
+			return
+		}
+
+		var config image.Config
+
+		decodersMu.RLock()
+		dec, found := decoders[i.Format]
+		decodersMu.RUnlock()
+
+		if _, err = f.Seek(0, io.SeekStart); err != nil {
+			return
+		}
+		if found {
+			config, err = dec(f)
+		} else {
+			config, _, err = image.DecodeConfig(f)
+		}
 		if err != nil {
 			return
 		}
 		i.config = config
+		i.metadata = loadMetadata(i.Format, data)
 	})
 
 	if err != nil {
@@ -161,6 +190,10 @@ type ImageProcessor struct {
 func (p *ImageProcessor) ApplyFiltersFromConfig(src image.Image, conf ImageConfig) (image.Image, error) {
 	var filters []gift.Filter
 
+	if conf.AutoOrient {
+		filters = append(filters, orientationFilters(conf.Orientation)...)
+	}
+
 	if conf.Rotate != 0 {
 		// Apply any rotation before any resize.
 		filters = append(filters, gift.Rotate(float32(conf.Rotate), color.Transparent, gift.NearestNeighborInterpolation))
@@ -192,17 +225,73 @@ func (p *ImageProcessor) ApplyFiltersFromConfig(src image.Image, conf ImageConfi
 	return p.Filter(src, filters...)
 }
 
+// tileFilterThreshold is the destination pixel count above which Filter
+// splits its work into tiles processed by a worker pool rather than doing a
+// single Draw call.
+const tileFilterThreshold = 2000 * 1000 // 2MP
+
+// Filter applies filters to src and returns the result. Filters built from
+// ApplyFiltersFromConfig are all geometric/per-pixel (resize, fill, fit,
+// rotate), so each output pixel can be computed independently of the
+// others; once the destination is large enough to be worth the goroutine
+// overhead, Filter exploits that by computing it in horizontal tiles on a
+// worker pool instead of a single Draw call. A filter whose output depends
+// on whole-image state (e.g. a histogram) would not be safe to tile this
+// way and should not be added to this package without revisiting this
+// function.
 func (p *ImageProcessor) Filter(src image.Image, filters ...gift.Filter) (image.Image, error) {
 	g := gift.New(filters...)
-	dst := image.NewRGBA(g.Bounds(src.Bounds()))
-	g.Draw(dst, src)
+	dstBounds := g.Bounds(src.Bounds())
+	dst := image.NewRGBA(dstBounds)
+
+	numWorkers := runtime.GOMAXPROCS(0)
+	height := dstBounds.Dy()
+	if dstBounds.Dx()*height < tileFilterThreshold || numWorkers < 2 || height < numWorkers {
+		g.Draw(dst, src)
+		return dst, nil
+	}
+
+	rowsPerTile := (height + numWorkers - 1) / numWorkers
+
+	var wg sync.WaitGroup
+	for y := dstBounds.Min.Y; y < dstBounds.Max.Y; y += rowsPerTile {
+		tileMaxY := y + rowsPerTile
+		if tileMaxY > dstBounds.Max.Y {
+			tileMaxY = dstBounds.Max.Y
+		}
+		tile := image.Rect(dstBounds.Min.X, y, dstBounds.Max.X, tileMaxY)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// pt is where the *full* filtered output's top-left corner lands
+			// in dst coordinates, not the tile's own offset: it must stay
+			// fixed at dstBounds.Min for every worker. tileDst's restricted
+			// Bounds() (from SubImage) is what actually confines this call
+			// to the tile's own rows.
+			tileDst := dst.SubImage(tile).(*image.RGBA)
+			g.DrawAt(tileDst, src, dstBounds.Min, gift.CopyOperator)
+		}()
+	}
+	wg.Wait()
+
 	return dst, nil
 }
 
-func (p *ImageProcessor) GetDefaultImageConfig(action string) ImageConfig {
+// GetDefaultImageConfig returns the default ImageConfig for action, with
+// Orientation/Metadata wired from src. That wiring is required, not
+// optional: ApplyFiltersFromConfig reads conf.Orientation and EncodeTo reads
+// conf.Metadata, so without it AutoOrient and metadata preservation would
+// look enabled (via p.Cfg) while silently doing nothing.
+func (p *ImageProcessor) GetDefaultImageConfig(action string, src *Image) ImageConfig {
+	md := src.Metadata()
 	return ImageConfig{
-		Action:  action,
-		Quality: p.Cfg.Quality,
+		Action:        action,
+		Quality:       p.Cfg.Quality,
+		AutoOrient:    p.Cfg.AutoOrient,
+		StripMetadata: p.Cfg.StripMetadata,
+		Orientation:   md.Orientation,
+		Metadata:      md,
 	}
 }
 
@@ -220,12 +309,15 @@ const (
 	GIF
 	TIFF
 	BMP
+	WEBP
 )
 
 type imageConfig struct {
 	config       image.Config
 	configInit   sync.Once
 	configLoaded bool
+
+	metadata Metadata
 }
 
 func imageConfigFromImage(img image.Image) image.Config {
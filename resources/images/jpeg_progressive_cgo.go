@@ -0,0 +1,30 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build cgo
+
+package images
+
+import (
+	"image"
+	"io"
+
+	libjpeg "github.com/pixiv/go-libjpeg/jpeg"
+)
+
+// encodeProgressiveJPEG is a cgo binding to libjpeg-turbo, so it's only
+// built into binaries compiled with CGO_ENABLED=1; see
+// jpeg_progressive_nocgo.go for the fallback.
+func encodeProgressiveJPEG(quality int, src image.Image, w io.Writer) error {
+	return libjpeg.Encode(w, src, &libjpeg.EncoderOptions{Quality: quality, ProgressiveMode: true})
+}
@@ -0,0 +1,58 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package images
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// TestEncodeInterlacedPNGUnassociatedAlpha guards against writing
+// image.RGBA's premultiplied samples straight into PNG, which stores
+// unassociated alpha: a semi-transparent pixel would otherwise come out
+// darkened after a decode/encode round trip.
+func TestEncodeInterlacedPNGUnassociatedAlpha(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	want := color.NRGBA{R: 200, G: 100, B: 50, A: 128}
+	src.Set(0, 0, want)
+
+	var buf bytes.Buffer
+	if err := encodeInterlacedPNG(src, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := color.NRGBAModel.Convert(decoded.At(0, 0)).(color.NRGBA)
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+// TestEncodePNGRejectsOptimizeAndInterlace ensures the two options, which
+// can't actually be combined, fail loudly instead of silently dropping one.
+func TestEncodePNGRejectsOptimizeAndInterlace(t *testing.T) {
+	conf := ImageConfig{Interlace: true, PNG: PNGConfig{Optimize: true}}
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+
+	if err := encodePNG(conf, src, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected an error when PNG.Optimize and Interlace are both set")
+	}
+}